@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +17,24 @@ import (
 type Object struct {
 	Name  string
 	Value any
+
+	// Group is set when this object is registered via WithGroupObject. It's used to resolve
+	// inject:"group:<name>" fields, and (unlike Name) several objects may share the same group.
+	Group string
+
+	// provider is set when this object is registered via WithProvider/WithNamedProvider. Its
+	// factory function is invoked during InitializeGraph (in dependency order) and the result
+	// becomes Value.
+	provider *provider
+}
+
+// provider describes a registered factory function of the form func(deps...) (T, error) or
+// func(deps...) T.
+type provider struct {
+	fn         reflect.Value
+	params     []reflect.Type
+	resultType reflect.Type
+	hasError   bool
 }
 
 type Initializer interface {
@@ -27,15 +46,39 @@ type Cleaner interface {
 	Clean() error
 }
 
+// InitializerCtx is preferred over Initializer when an object implements both. Its ctx is the
+// one passed to WithContext (context.Background() by default); if it's canceled before this
+// object's turn, InitializeGraph skips the remaining Init calls and rolls back the ones already
+// run.
+type InitializerCtx interface {
+	Init(ctx context.Context) error
+}
+
+// CleanerCtx is preferred over Cleaner when an object implements both. Stop derives ctx with
+// context.WithTimeout(maxDuration), so a well-behaved cleaner can watch ctx.Done() and abort its
+// own work as the deadline approaches, instead of being abandoned mid-cleanup.
+type CleanerCtx interface {
+	Clean(ctx context.Context) error
+}
+
 type Injector struct {
 	// this slice is here because we want to initialize objects in the order as they are added (after the graph is generated):
 	c           context.Context
 	objects     []*Object
+	edges       []depEdge
 	stopped     bool
 	Logger      func(c context.Context, format string, v ...interface{})
 	FatalLogger func(c context.Context, format string, v ...interface{})
 }
 
+// depEdge records that target depends on src, because src was assigned into target's field
+// (named field, so that a cycle can be reported with the field names involved).
+type depEdge struct {
+	target *Object
+	field  string
+	src    *Object
+}
+
 // NewDebug starts a new injector with debug output
 func NewDebug() *Injector {
 	di := New()
@@ -55,6 +98,13 @@ func (i *Injector) WithLogger(logger func(c context.Context, format string, v ..
 	return i
 }
 
+// WithContext sets the context passed to InitializerCtx/CleanerCtx implementations, and the one
+// Stop derives its per-call timeout from. Defaults to context.Background().
+func (i *Injector) WithContext(ctx context.Context) *Injector {
+	i.c = ctx
+	return i
+}
+
 func (i *Injector) log(c context.Context, format string, v ...interface{}) {
 	if i.Logger != nil {
 		i.Logger(c, format, v...)
@@ -79,10 +129,14 @@ func (i *Injector) WithObjects(objects ...interface{}) *Injector {
 	return i
 }
 
+// WithObject registers an unnamed object. Only one unnamed object of a given concrete type may
+// exist (that's what makes it resolvable by an untagged inject:"" field without ambiguity); use
+// WithNamedObject to register several instances of the same type, e.g. two DB handles or two
+// Kafka clients with different configs - each under its own name.
 func (i *Injector) WithObject(object interface{}) *Injector {
 	for _, o := range i.objects {
-		if o.Name == "" {
-			if reflect.TypeOf(o.Value) == reflect.TypeOf(object) {
+		if o.Name == "" && o.Group == "" {
+			if i.objectType(o) == reflect.TypeOf(object) {
 				i.logAndPanic(i.c, "Object with type %s already exists", reflect.TypeOf(object).String())
 			}
 		}
@@ -93,6 +147,10 @@ func (i *Injector) WithObject(object interface{}) *Injector {
 	return i
 }
 
+// WithNamedObject registers obj under name. Names, not types, are what must be unique here: two
+// (or more) named objects of the same concrete type are allowed, as long as each has a distinct
+// name. An untagged inject:"" field only matches unnamed objects (see getCandidatesForField), so
+// named objects are never its candidates; only a second object under the same name is rejected.
 func (i *Injector) WithNamedObject(name string, obj interface{}) *Injector {
 	if name == "" {
 		i.logAndPanic(i.c, "Named object must have a name")
@@ -110,6 +168,213 @@ func (i *Injector) WithNamedObject(name string, obj interface{}) *Injector {
 	return i
 }
 
+// WithGroupObject registers obj as a member of the named group, for fields tagged
+// inject:"group:<group>" (see injectGroup). Unlike WithObject, several objects of the
+// same concrete type may share a group - that's the point of group bindings, e.g. N HTTP
+// handlers or N background workers collected into a single []Handler/[]Worker field.
+func (i *Injector) WithGroupObject(group string, obj interface{}) *Injector {
+	if group == "" {
+		i.logAndPanic(i.c, "Group object must have a group")
+	}
+	i.log(i.c, "Adding %T to group %s", obj, group)
+	o := &Object{Group: group, Value: obj}
+	i.objects = append(i.objects, o)
+	return i
+}
+
+var errType = reflect.TypeOf((*error)(nil)).Elem()
+
+// newProvider validates fn and builds its provider descriptor.
+func (i *Injector) newProvider(fn any) *provider {
+	fnVal := reflect.ValueOf(fn)
+	fnType := fnVal.Type()
+	if fnType.Kind() != reflect.Func {
+		i.logAndPanic(i.c, "Provider must be a function, found %T", fn)
+	}
+	switch fnType.NumOut() {
+	case 1:
+	case 2:
+		if !fnType.Out(1).Implements(errType) {
+			i.logAndPanic(i.c, "Provider %s must return (T) or (T, error)", fnType.String())
+		}
+	default:
+		i.logAndPanic(i.c, "Provider %s must return (T) or (T, error)", fnType.String())
+	}
+	params := make([]reflect.Type, fnType.NumIn())
+	for n := 0; n < fnType.NumIn(); n++ {
+		params[n] = fnType.In(n)
+	}
+	return &provider{
+		fn:         fnVal,
+		params:     params,
+		resultType: fnType.Out(0),
+		hasError:   fnType.NumOut() == 2,
+	}
+}
+
+// objectType returns the (eventual) concrete type of an object, whether it was registered
+// directly or via a provider that hasn't run yet.
+func (i *Injector) objectType(o *Object) reflect.Type {
+	if o.provider != nil {
+		return o.provider.resultType
+	}
+	return reflect.TypeOf(o.Value)
+}
+
+// WithProvider registers a factory function of the form func(deps...) (T, error) or
+// func(deps...) T. Its parameters are resolved from the objects registered with the injector
+// (matching by type, the same way untagged inject:"" fields are resolved) and the function is
+// invoked during InitializeGraph, in dependency order, with the returned T registered as an
+// unnamed object. If the factory returns a non-nil error, InitializeGraph panics via
+// logAndPanic.
+func (i *Injector) WithProvider(fn any) *Injector {
+	return i.withProvider("", fn)
+}
+
+// WithNamedProvider is like WithProvider, but registers the produced object under name.
+func (i *Injector) WithNamedProvider(name string, fn any) *Injector {
+	if name == "" {
+		i.logAndPanic(i.c, "Named provider must have a name")
+	}
+	return i.withProvider(name, fn)
+}
+
+func (i *Injector) withProvider(name string, fn any) *Injector {
+	p := i.newProvider(fn)
+	if name == "" {
+		for _, o := range i.objects {
+			if o.Name == "" && o.Group == "" && i.objectType(o) == p.resultType {
+				i.logAndPanic(i.c, "Object with type %s already exists", p.resultType.String())
+			}
+		}
+	} else {
+		for _, o := range i.objects {
+			if o.Name == name {
+				i.logAndPanic(i.c, "Object with name %s already exists", name)
+			}
+		}
+	}
+	i.log(i.c, "Adding provider %s: %s", name, p.resultType.String())
+	i.objects = append(i.objects, &Object{Name: name, provider: p})
+	return i
+}
+
+// runProviders runs every registered provider, in dependency order, and assigns its result as
+// the Value of its Object. Providers whose parameters aren't satisfiable by any other provider
+// or WithObject-registered instance panic when they're run (as "no candidates"); a cycle among
+// providers panics immediately with the cycle path.
+func (i *Injector) runProviders() {
+	pending := map[*Object]bool{}
+	for _, o := range i.objects {
+		if o.provider != nil {
+			pending[o] = true
+		}
+	}
+	for len(pending) > 0 {
+		progressed := false
+		for _, o := range i.objects {
+			if !pending[o] {
+				continue
+			}
+			if len(i.pendingProviderDeps(o, pending)) > 0 {
+				continue
+			}
+			i.runProvider(o)
+			delete(pending, o)
+			progressed = true
+		}
+		if !progressed {
+			i.logAndPanic(i.c, "Dependency cycle between providers: %s", i.providerCyclePath(pending))
+		}
+	}
+}
+
+// pendingProviderDeps returns the still-pending providers that feed one of o's parameters.
+func (i *Injector) pendingProviderDeps(o *Object, pending map[*Object]bool) []*Object {
+	var deps []*Object
+	for _, paramType := range o.provider.params {
+		for other := range pending {
+			if i.objectType(other).AssignableTo(paramType) {
+				deps = append(deps, other)
+				break
+			}
+		}
+	}
+	return deps
+}
+
+// providerCyclePath builds a human-readable cycle path among the still-pending providers (used
+// when they can no longer make progress).
+func (i *Injector) providerCyclePath(pending map[*Object]bool) string {
+	var start *Object
+	for o := range pending {
+		start = o
+		break
+	}
+	path := []string{start.provider.resultType.String()}
+	visited := map[*Object]bool{start: true}
+	cur := start
+	for {
+		var next *Object
+		for _, paramType := range cur.provider.params {
+			for other := range pending {
+				if i.objectType(other).AssignableTo(paramType) {
+					next = other
+					break
+				}
+			}
+			if next != nil {
+				break
+			}
+		}
+		if next == nil {
+			return strings.Join(path, " -> ")
+		}
+		path = append(path, next.provider.resultType.String())
+		if visited[next] {
+			return strings.Join(path, " -> ")
+		}
+		visited[next] = true
+		cur = next
+	}
+}
+
+func (i *Injector) runProvider(o *Object) {
+	p := o.provider
+	in := make([]reflect.Value, len(p.params))
+	for n, paramType := range p.params {
+		in[n] = reflect.ValueOf(i.resolveProviderParam(paramType))
+	}
+	i.log(i.c, "Running provider for %s", p.resultType.String())
+	out := p.fn.Call(in)
+	if p.hasError {
+		if errVal, _ := out[1].Interface().(error); errVal != nil {
+			i.logAndPanic(i.c, "Error running provider for %s: %s", p.resultType.String(), errVal.Error())
+		}
+	}
+	o.Value = out[0].Interface()
+	i.log(i.c, "Provider produced %T", o.Value)
+}
+
+func (i *Injector) resolveProviderParam(t reflect.Type) any {
+	var candidates []any
+	for _, o := range i.objects {
+		if o.Name != "" || o.Group != "" || o.Value == nil {
+			continue
+		}
+		if reflect.TypeOf(o.Value).AssignableTo(t) {
+			candidates = append(candidates, o.Value)
+		}
+	}
+	if len(candidates) == 0 {
+		i.logAndPanic(i.c, "No candidates for provider param %s", t.String())
+	}
+	if len(candidates) > 1 {
+		i.logAndPanic(i.c, "%d candidates (instead of 1) for provider param %s", len(candidates), t.String())
+	}
+	return candidates[0]
+}
+
 func (i *Injector) AllObjects() []interface{} {
 	//if len(i.g.Objects()) != len(i.objects) { panic(fmt.Sprintf("Invalid objects size: %d!=%d", len(i.g.Objects()), len(i.objects))) }
 	res := []interface{}{}
@@ -128,7 +393,7 @@ func (i Injector) MustGetNamedObject(sample interface{}, name string) interface{
 		i.logAndPanic(i.c, "Sample must be interface, found %T", sample)
 	}
 	for _, obj := range i.objects {
-		if reflect.TypeOf(obj.Value) == sampleType && obj.Name == name {
+		if reflect.TypeOf(obj.Value) == sampleType && obj.Name == name && (name != "" || obj.Group == "") {
 			return obj.Value
 		}
 	}
@@ -141,16 +406,62 @@ func (i Injector) MustGetObject(sample interface{}) interface{} {
 	return i.MustGetNamedObject(sample, "")
 }
 
-func (i *Injector) getCandidatesForField(obj any, fld reflector.ObjField, tag string) []any {
-	var candidates []any
+// parseGroupTag recognizes the inject:"group"/inject:"all" (every registered object assignable
+// to the slice's element type) and inject:"group:<name>" (only WithGroupObject members of
+// <name>) tag forms.
+func parseGroupTag(tag string) (group string, isGroup bool) {
+	if tag == "group" || tag == "all" {
+		return "", true
+	}
+	if rest, ok := strings.CutPrefix(tag, "group:"); ok {
+		return rest, true
+	}
+	return "", false
+}
+
+// injectGroup assembles every candidate for a inject:"group"/inject:"group:<name>" slice field
+// and assigns it, preserving registration order. group == "" means the untargeted form, which
+// matches any registered object (named, unnamed, or in any WithGroupObject group) assignable to
+// the slice's element type; a non-empty group only matches WithGroupObject members of that group.
+func (i *Injector) injectGroup(o any, fld reflector.ObjField, group string, target *Object) {
+	if fld.Type().Kind() != reflect.Slice {
+		i.logAndPanic(i.c, "inject:\"group\" field %T.%s must be a slice, found %s", o, fld.Name(), fld.Type().String())
+	}
+	elemType := fld.Type().Elem()
+	slice := reflect.MakeSlice(fld.Type(), 0, 0)
+	for _, candidate := range i.objects {
+		if candidate == target || candidate.Value == nil {
+			continue
+		}
+		if group != "" && candidate.Group != group {
+			continue
+		}
+		if !reflect.TypeOf(candidate.Value).AssignableTo(elemType) {
+			continue
+		}
+		i.log(i.c, "assigning %T.%s (%s) <-> %T (group %s)", o, fld.Name(), fld.Type().String(), candidate.Value, candidate.Group)
+		slice = reflect.Append(slice, reflect.ValueOf(candidate.Value))
+		i.edges = append(i.edges, depEdge{target: target, field: fld.Name(), src: candidate})
+	}
+	if err := fld.Set(slice.Interface()); err != nil {
+		i.logAndPanic(i.c, "error setting %T.%s <-> %s", o, fld.Name(), fld.Type().String())
+	}
+	i.log(i.c, "initialized group field %T.%s with %d candidates", o, fld.Name(), slice.Len())
+}
+
+// getCandidatesForField returns the Objects (not just their Values) matching fld's tag, so that
+// callers can record dependency edges against the owning Object without having to find it back by
+// comparing Values - that comparison panics for values whose type contains a slice/map/func field.
+func (i *Injector) getCandidatesForField(obj any, fld reflector.ObjField, tag string) []*Object {
+	var candidates []*Object
 	switch tag {
 	case "":
 		for m := range i.objects {
-			if i.objects[m].Name == "" {
+			if i.objects[m].Name == "" && i.objects[m].Group == "" {
 				// fmt.Printf("checking %T.%s (%s) and %T (%s)\n", i.objects[n].Value, fld.Name(), fld.Type().String(), i.objects[m].Value, i.objects[m].Name)
 				if reflect.TypeOf(i.objects[m].Value).AssignableTo(fld.Type()) {
 					i.log(i.c, "assigning %T.%s (%s) <-> %T (%s)", obj, fld.Name(), fld.Type().String(), i.objects[m].Value, i.objects[m].Name)
-					candidates = append(candidates, i.objects[m].Value)
+					candidates = append(candidates, i.objects[m])
 				}
 			}
 		}
@@ -159,14 +470,18 @@ func (i *Injector) getCandidatesForField(obj any, fld reflector.ObjField, tag st
 		for m := range i.objects {
 			if i.objects[m].Name == tag {
 				i.log(i.c, "assigning %T.%s (%s) <-> %T (%s)", obj, fld.Name(), fld.Type().String(), i.objects[m].Value, i.objects[m].Name)
-				candidates = append(candidates, i.objects[m].Value)
+				candidates = append(candidates, i.objects[m])
 			}
 		}
 	}
 	return candidates
 }
 
-func (i *Injector) inject(o any) {
+// inject assigns o's tagged fields. target is the Object o belongs to, used to record dependency
+// edges (target depends on whatever gets assigned into its fields); for inline embeds, o is the
+// embedded struct but target stays the owning Object, so the embed's dependencies are recorded
+// against it.
+func (i *Injector) inject(o any, target *Object) {
 	i.log(i.c, "initializing fields of %T", o)
 	obj := reflector.New(o)
 fld_loop:
@@ -183,9 +498,11 @@ fld_loop:
 			// Recursive for other fields
 			i.log(i.c, "initializing inline field %T.%s", o, fld.Name())
 			inlineObj := reflect.New(fld.Type()).Interface()
-			i.inject(inlineObj)
+			i.inject(inlineObj, target)
 			fld.Set(reflect.ValueOf(inlineObj).Elem().Interface())
 			i.log(i.c, "initialized inline field %T.%s", o, fld.Name())
+		} else if group, isGroup := parseGroupTag(name); isGroup {
+			i.injectGroup(o, fld, group, target)
 		} else {
 			i.log(i.c, "initializing field %T.%s", o, fld.Name())
 			candidates := i.getCandidatesForField(o, fld, name)
@@ -195,31 +512,196 @@ fld_loop:
 			if len(candidates) > 1 {
 				i.logAndPanic(i.c, "%d candidates (instead of 1) for %T.%s (%s)", len(candidates), o, fld.Name(), fld.Type().String())
 			}
-			if err := fld.Set(candidates[0]); err != nil {
-				i.logAndPanic(i.c, "error setting %T.%s <-> %T", o, fld.Name(), candidates[0])
+			src := candidates[0]
+			if err := fld.Set(src.Value); err != nil {
+				i.logAndPanic(i.c, "error setting %T.%s <-> %T", o, fld.Name(), src.Value)
+			}
+			if src != target {
+				i.edges = append(i.edges, depEdge{target: target, field: fld.Name(), src: src})
 			}
 			i.log(i.c, "initialized field %T.%s", o, fld.Name())
 		}
 	}
 }
 
+// topoLayers groups the registered objects into dependency layers: layer 0 has no recorded
+// dependencies (see inject), layer N only depends on objects in layers < N. Objects within the
+// same layer don't depend on each other (directly or transitively) and are otherwise ordered as
+// they were registered. Panics via logAndPanic, with the full cycle path, if the dependency graph
+// has a cycle.
+func (i *Injector) topoLayers() [][]*Object {
+	deps := map[*Object]map[*Object]bool{}
+	for _, o := range i.objects {
+		deps[o] = map[*Object]bool{}
+	}
+	for _, e := range i.edges {
+		deps[e.target][e.src] = true
+	}
+
+	done := map[*Object]bool{}
+	var layers [][]*Object
+	for len(done) < len(i.objects) {
+		var layer []*Object
+		for _, o := range i.objects {
+			if done[o] {
+				continue
+			}
+			ready := true
+			for dep := range deps[o] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				layer = append(layer, o)
+			}
+		}
+		if len(layer) == 0 {
+			i.logAndPanic(i.c, "Dependency cycle: %s", i.objectCyclePath())
+		}
+		for _, o := range layer {
+			done[o] = true
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+// objectCyclePath finds a cycle in the recorded dependency edges and formats it as
+// "A.B -> B.C -> C.A", meaning A's field B depends on B, whose field C depends on C, whose field
+// A depends back on A.
+func (i *Injector) objectCyclePath() string {
+	adjacency := map[*Object][]depEdge{}
+	for _, e := range i.edges {
+		adjacency[e.target] = append(adjacency[e.target], e)
+	}
+
+	const unvisited, inProgress, done = 0, 1, 2
+	state := map[*Object]int{}
+	var path []depEdge
+
+	var visit func(o *Object) bool
+	visit = func(o *Object) bool {
+		state[o] = inProgress
+		for _, e := range adjacency[o] {
+			path = append(path, e)
+			if state[e.src] == inProgress {
+				return true
+			}
+			if state[e.src] == unvisited && visit(e.src) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		state[o] = done
+		return false
+	}
+
+	for _, o := range i.objects {
+		if state[o] == unvisited {
+			path = nil
+			if visit(o) {
+				return formatCyclePath(path)
+			}
+		}
+	}
+	return "(cycle could not be reconstructed)"
+}
+
+func formatCyclePath(path []depEdge) string {
+	cycleStart := path[len(path)-1].src
+	startIdx := 0
+	for idx, e := range path {
+		if e.target == cycleStart {
+			startIdx = idx
+			break
+		}
+	}
+	cyclePath := path[startIdx:]
+	parts := make([]string, len(cyclePath))
+	for n, e := range cyclePath {
+		parts[n] = fmt.Sprintf("%T.%s", e.target.Value, e.field)
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// initOne runs o's Init, preferring InitializerCtx (passing the injector's context) over the
+// plain Initializer.
+func (i *Injector) initOne(o *Object) {
+	if initializer, is := o.Value.(InitializerCtx); is {
+		i.log(i.c, "Initializing %T", o.Value)
+		if err := initializer.Init(i.c); err != nil {
+			i.logAndPanic(i.c, "Error initializing privided object %T:%s", o.Value, err.Error())
+		}
+		i.log(i.c, "Initialized %T", o.Value)
+		return
+	}
+	if initializer, is := o.Value.(Initializer); is {
+		i.log(i.c, "Initializing %T", o.Value)
+		if err := initializer.Init(); err != nil {
+			i.logAndPanic(i.c, "Error initializing privided object %T:%s", o.Value, err.Error())
+		}
+		i.log(i.c, "Initialized %T", o.Value)
+	}
+}
+
+// cleanOne runs o's Clean (rollback use, see InitializeGraph), preferring CleanerCtx over the
+// plain Cleaner. ctx is passed to CleanerCtx and must not be the (canceled) context that triggered
+// the rollback, or a CleanerCtx following the documented "watch ctx.Done() and abort" contract
+// would see it already canceled and skip its own cleanup. Errors are logged, not panicked on: a
+// rollback already means things went wrong.
+func (i *Injector) cleanOne(ctx context.Context, o *Object) {
+	if cleaner, is := o.Value.(CleanerCtx); is {
+		if err := cleaner.Clean(ctx); err != nil {
+			i.log(ctx, "Error rolling back %T: %s", o.Value, err.Error())
+		}
+		return
+	}
+	if cleaner, is := o.Value.(Cleaner); is {
+		if err := cleaner.Clean(); err != nil {
+			i.log(ctx, "Error rolling back %T: %s", o.Value, err.Error())
+		}
+	}
+}
+
 // InitializeGraph initializes a graph, but fails if an object is not specified with one of the With() methods.
+// Init() is called in dependency order (see topoLayers). If the injector's context (see
+// WithContext) is canceled before every object is initialized, the remaining Init calls are
+// skipped, the already-initialized objects are cleaned in reverse order as a rollback (using a
+// fresh, non-canceled context, since the one that triggered the rollback is already done), and
+// InitializeGraph panics via logAndPanic to signal that initialization did not complete.
 func (i *Injector) InitializeGraph() *Injector {
 	i.log(i.c, "Initializing %d objects", len(i.objects))
 
+	i.runProviders()
+
 	for n := range i.objects {
-		i.inject(i.objects[n].Value)
+		i.inject(i.objects[n].Value, i.objects[n])
 	}
 
-	for _, obj := range i.AllObjects() {
-		// TODO: Check that it doesn't depend on an unitialized object
-		if initializer, is := obj.(Initializer); is {
-			i.log(i.c, "Initializing %T", obj)
-			if err := initializer.Init(); err != nil {
-				i.logAndPanic(i.c, "Error initializing privided object %T:%s", obj, err.Error())
+	var initialized []*Object
+initLoop:
+	for _, layer := range i.topoLayers() {
+		for _, o := range layer {
+			select {
+			case <-i.c.Done():
+				i.log(i.c, "Context canceled, skipping remaining Init calls")
+				break initLoop
+			default:
 			}
-			i.log(i.c, "Initialized %T", obj)
+			i.initOne(o)
+			initialized = append(initialized, o)
+		}
+	}
+
+	if i.c.Err() != nil {
+		rollbackCtx := context.Background()
+		for n := len(initialized) - 1; n >= 0; n-- {
+			i.cleanOne(rollbackCtx, initialized[n])
 		}
+		i.stopped = true
+		i.logAndPanic(i.c, "Context canceled after initializing %d/%d objects, rolled back: %s", len(initialized), len(i.objects), i.c.Err().Error())
 	}
 
 	return i
@@ -236,6 +718,12 @@ func (i *Injector) WithCleanBeforeShutdown(maxDuration time.Duration, sig ...os.
 	return i
 }
 
+// Stop cleans every object in reverse dependency order. For CleanerCtx, maxDuration bounds the
+// whole call: it's used once to derive a context.WithTimeout passed to every CleanerCtx, so a
+// CleanerCtx in a later layer can see its deadline already mostly spent by earlier layers. A plain
+// Cleaner, by contrast, still gets its own fresh maxDuration per call (see cleanCleanable), so with
+// plain Cleaners in several layers Stop can take longer than maxDuration in total - the two
+// interfaces are not held to the same budget, so prefer CleanerCtx when that distinction matters.
 func (i *Injector) Stop(maxDuration time.Duration, exit bool) {
 	if i.stopped {
 		fmt.Fprintf(os.Stderr, "Stop already called")
@@ -243,22 +731,43 @@ func (i *Injector) Stop(maxDuration time.Duration, exit bool) {
 	}
 	i.stopped = true
 
-	errorsChan := make(chan error, len(i.AllObjects()))
-	wg := new(sync.WaitGroup)
-	for _, obj := range i.AllObjects() {
-		if cleaner, is := obj.(Cleaner); is {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				if err := i.cleanCleanable(cleaner, maxDuration); err != nil {
-					errorsChan <- err
-				}
-			}()
+	ctx, cancel := context.WithTimeout(i.c, maxDuration)
+	defer cancel()
+
+	layers := i.topoLayers()
+	anyErrors := false
+	for n := len(layers) - 1; n >= 0; n-- {
+		layer := layers[n]
+		errorsChan := make(chan error, len(layer))
+		wg := new(sync.WaitGroup)
+		for _, o := range layer {
+			if cleanerCtx, is := o.Value.(CleanerCtx); is {
+				wg.Add(1)
+				go func(cleanerCtx CleanerCtx) {
+					defer wg.Done()
+					if err := i.cleanCleanableCtx(cleanerCtx, ctx); err != nil {
+						errorsChan <- err
+					}
+				}(cleanerCtx)
+				continue
+			}
+			if cleaner, is := o.Value.(Cleaner); is {
+				wg.Add(1)
+				go func(cleaner Cleaner) {
+					defer wg.Done()
+					if err := i.cleanCleanable(cleaner, maxDuration); err != nil {
+						errorsChan <- err
+					}
+				}(cleaner)
+			}
+		}
+		wg.Wait()
+		if len(errorsChan) > 0 {
+			anyErrors = true
 		}
 	}
-	wg.Wait()
 
-	if len(errorsChan) == 0 {
+	if !anyErrors {
 		i.log(i.c, "all cleaned => exit")
 		if exit {
 			os.Exit(0)
@@ -304,6 +813,35 @@ func (i *Injector) cleanCleanable(cleaner Cleaner, maxDuratiDuration time.Durati
 	}
 }
 
+// cleanCleanableCtx cleans a CleanerCtx, passing it ctx (already carrying Stop's maxDuration
+// timeout) so it can watch ctx.Done() and abort on its own instead of being abandoned by a
+// racing goroutine once the deadline passes, the way cleanCleanable has to for the ctx-less
+// Cleaner.
+func (i *Injector) cleanCleanableCtx(cleaner CleanerCtx, ctx context.Context) error {
+	done := make(chan error, 1)
+
+	go func() {
+		i.log(ctx, "Cleaning %T", cleaner)
+		defer i.log(ctx, "Cleaned %T", cleaner)
+		done <- cleaner.Clean(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			msg := fmt.Sprintf("Error cleaning %T: %+v", cleaner, err)
+			i.log(ctx, msg)
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		return err
+	case <-ctx.Done():
+		msg := fmt.Sprintf("Cleaning %T did not finish before the deadline: %s", cleaner, ctx.Err())
+		fmt.Fprintln(os.Stderr, msg)
+		i.log(ctx, msg)
+		return ctx.Err()
+	}
+}
+
 func (i *Injector) Stopper(maxDuration time.Duration, exitAfterStop bool) func() {
 	return func() {
 		i.Stop(maxDuration, exitAfterStop)
@@ -1,7 +1,10 @@
 package injector
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -172,6 +175,23 @@ func TestDoubleType(t *testing.T) {
 	assert.Fail(t, "Must panic")
 }
 
+func TestDoubleTypeProviderThenObject(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Recovered in f", r)
+		}
+	}()
+
+	type Aaa struct{}
+
+	New().
+		WithProvider(func() *Aaa { return new(Aaa) }).
+		WithObject(new(Aaa)).
+		InitializeGraph()
+
+	assert.Fail(t, "Must panic")
+}
+
 func TestDoubleNamed(t *testing.T) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -214,3 +234,496 @@ func TestInlineOverwritingFields(t *testing.T) {
 	assert.Equal(t, logger, service.Aaa.Logger)
 	assert.Equal(t, logger, service.Bbb.Aaa.Logger)
 }
+
+func TestProviderSimple(t *testing.T) {
+	type Config struct {
+		DSN string
+	}
+	type DB struct {
+		DSN string
+	}
+
+	di := New().
+		WithObject(&Config{DSN: "localhost"}).
+		WithProvider(func(cfg *Config) (*DB, error) {
+			return &DB{DSN: cfg.DSN}, nil
+		}).
+		InitializeGraph()
+
+	db := di.MustGetObject(&DB{}).(*DB)
+	assert.Equal(t, "localhost", db.DSN)
+}
+
+func TestProviderNoError(t *testing.T) {
+	type DB struct{}
+
+	di := New().
+		WithProvider(func() *DB {
+			return &DB{}
+		}).
+		InitializeGraph()
+
+	assert.NotNil(t, di.MustGetObject(&DB{}))
+}
+
+func TestProviderChainedDependencies(t *testing.T) {
+	type Config struct{}
+	type DB struct{}
+	type Repo struct {
+		DB *DB `inject:""`
+	}
+
+	di := New().
+		WithProvider(func(db *DB) (*Repo, error) {
+			return &Repo{}, nil
+		}).
+		WithProvider(func(cfg *Config) (*DB, error) {
+			return &DB{}, nil
+		}).
+		WithObject(&Config{}).
+		InitializeGraph()
+
+	repo := di.MustGetObject(&Repo{}).(*Repo)
+	assert.NotNil(t, repo.DB)
+}
+
+func TestNamedProvider(t *testing.T) {
+	type DB struct{}
+
+	di := New().
+		WithNamedProvider("primary", func() *DB {
+			return &DB{}
+		}).
+		InitializeGraph()
+
+	assert.NotNil(t, di.MustGetNamedObject(&DB{}, "primary"))
+}
+
+func TestProviderErrorPanics(t *testing.T) {
+	type DB struct{}
+
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+	}()
+
+	New().
+		WithProvider(func() (*DB, error) {
+			return nil, errors.New("connection refused")
+		}).
+		InitializeGraph()
+
+	t.Fail()
+}
+
+func TestProviderCycle(t *testing.T) {
+	type A struct{}
+	type B struct{}
+
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+		fmt.Println("Recovered in f", r)
+	}()
+
+	New().
+		WithProvider(func(b *B) (*A, error) { return &A{}, nil }).
+		WithProvider(func(a *A) (*B, error) { return &B{}, nil }).
+		InitializeGraph()
+
+	t.Fail()
+}
+
+type orderedInitializer struct {
+	name   string
+	order  *[]string
+	onInit func()
+}
+
+func (o *orderedInitializer) Init() error {
+	*o.order = append(*o.order, o.name)
+	if o.onInit != nil {
+		o.onInit()
+	}
+	return nil
+}
+
+func TestInjectionOfUncomparableValue(t *testing.T) {
+	type cfgWithSlice struct {
+		Tags []string
+	}
+	type consumerOfCfg struct {
+		Cfg cfgWithSlice `inject:""`
+	}
+
+	New().
+		WithObjects(new(consumerOfCfg), cfgWithSlice{Tags: []string{"a"}}).
+		InitializeGraph()
+}
+
+func TestInitOrderDiamondRespectsDependencies(t *testing.T) {
+	var order []string
+
+	type A struct {
+		orderedInitializer
+	}
+	type B struct {
+		orderedInitializer
+		A *A `inject:""`
+	}
+	type C struct {
+		orderedInitializer
+		A *A `inject:""`
+	}
+	type D struct {
+		orderedInitializer
+		B *B `inject:""`
+		C *C `inject:""`
+	}
+
+	a := &A{orderedInitializer{name: "A", order: &order}}
+	b := &B{orderedInitializer: orderedInitializer{name: "B", order: &order}}
+	c := &C{orderedInitializer: orderedInitializer{name: "C", order: &order}}
+	d := &D{orderedInitializer: orderedInitializer{name: "D", order: &order}}
+
+	New().
+		WithObjects(d, c, b, a).
+		InitializeGraph()
+
+	assert.Equal(t, "A", order[0])
+	assert.ElementsMatch(t, []string{"B", "C"}, order[1:3])
+	assert.Equal(t, "D", order[3])
+}
+
+type orderedCleaner struct {
+	name  string
+	mu    *sync.Mutex
+	order *[]string
+}
+
+func (c *orderedCleaner) Clean() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.order = append(*c.order, c.name)
+	return nil
+}
+
+func TestStopOrderRespectsDependencies(t *testing.T) {
+	var order []string
+	var mu sync.Mutex
+
+	type A struct {
+		orderedCleaner
+	}
+	type B struct {
+		orderedCleaner
+		A *A `inject:""`
+	}
+
+	a := &A{orderedCleaner{name: "A", mu: &mu, order: &order}}
+	b := &B{orderedCleaner: orderedCleaner{name: "B", mu: &mu, order: &order}}
+
+	di := New().
+		WithObjects(b, a).
+		InitializeGraph()
+
+	di.Stop(time.Minute, false)
+
+	assert.Equal(t, []string{"B", "A"}, order)
+}
+
+type cycleA struct {
+	B *cycleB `inject:""`
+}
+type cycleB struct {
+	A *cycleA `inject:""`
+}
+
+func TestInitCycleDetected(t *testing.T) {
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+		fmt.Println("Recovered in f", r)
+	}()
+
+	New().
+		WithObjects(new(cycleA), new(cycleB)).
+		InitializeGraph()
+
+	t.Fail()
+}
+
+func TestTwoNamedInstancesOfSameType(t *testing.T) {
+	type DB struct {
+		DSN string
+	}
+
+	a := &DB{DSN: "a"}
+	b := &DB{DSN: "b"}
+
+	di := New().
+		WithNamedObject("foo", a).
+		WithNamedObject("bar", b).
+		InitializeGraph()
+
+	assert.Same(t, a, di.MustGetNamedObject(&DB{}, "foo"))
+	assert.Same(t, b, di.MustGetNamedObject(&DB{}, "bar"))
+}
+
+func TestUntaggedFieldIgnoresNamedDuplicates(t *testing.T) {
+	type DB struct{}
+	type Repo struct {
+		DB *DB `inject:""`
+	}
+
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+		fmt.Println("Recovered in f", r)
+	}()
+
+	New().
+		WithNamedObject("foo", new(DB)).
+		WithNamedObject("bar", new(DB)).
+		WithObject(new(Repo)).
+		InitializeGraph()
+
+	t.Fail()
+}
+
+type Handler interface {
+	Handle() string
+}
+
+type handlerA struct{}
+
+func (h *handlerA) Handle() string { return "a" }
+
+type handlerB struct{}
+
+func (h *handlerB) Handle() string { return "b" }
+
+func TestGroupInjection(t *testing.T) {
+	type Dispatcher struct {
+		Handlers []Handler `inject:"group"`
+	}
+
+	d := new(Dispatcher)
+
+	New().
+		WithObjects(d, &handlerA{}, &handlerB{}).
+		InitializeGraph()
+
+	assert.Len(t, d.Handlers, 2)
+	assert.Equal(t, "a", d.Handlers[0].Handle())
+	assert.Equal(t, "b", d.Handlers[1].Handle())
+}
+
+func TestNamedGroupInjection(t *testing.T) {
+	type Dispatcher struct {
+		Handlers []Handler `inject:"group:handlers"`
+	}
+
+	d := new(Dispatcher)
+
+	New().
+		WithObject(d).
+		WithGroupObject("handlers", &handlerA{}).
+		WithGroupObject("handlers", &handlerB{}).
+		WithGroupObject("other", &handlerA{}).
+		InitializeGraph()
+
+	assert.Len(t, d.Handlers, 2)
+	assert.Equal(t, "a", d.Handlers[0].Handle())
+	assert.Equal(t, "b", d.Handlers[1].Handle())
+}
+
+func TestGroupInjectionEmpty(t *testing.T) {
+	type Dispatcher struct {
+		Handlers []Handler `inject:"group:workers"`
+	}
+
+	d := new(Dispatcher)
+
+	New().
+		WithObject(d).
+		InitializeGraph()
+
+	assert.Empty(t, d.Handlers)
+}
+
+func TestGroupMemberDoesNotLeakIntoUntaggedField(t *testing.T) {
+	type Consumer struct {
+		S *handlerA `inject:""`
+	}
+
+	c := new(Consumer)
+
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+		fmt.Println("Recovered in f", r)
+	}()
+
+	New().
+		WithObject(c).
+		WithGroupObject("g", &handlerA{}).
+		InitializeGraph()
+
+	t.Fail()
+}
+
+func TestGroupMemberDoesNotLeakIntoUntaggedProviderParam(t *testing.T) {
+	type Consumer struct{}
+
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+		fmt.Println("Recovered in f", r)
+	}()
+
+	New().
+		WithGroupObject("g", &handlerA{}).
+		WithProvider(func(h *handlerA) *Consumer { return new(Consumer) }).
+		InitializeGraph()
+
+	t.Fail()
+}
+
+type ctxInitializableStruct struct {
+	receivedCtx context.Context
+}
+
+func (s *ctxInitializableStruct) Init(ctx context.Context) error {
+	s.receivedCtx = ctx
+	return nil
+}
+
+var _ InitializerCtx = (*ctxInitializableStruct)(nil)
+
+type ctxStoppableStruct struct {
+	receivedCtx context.Context
+}
+
+func (s *ctxStoppableStruct) Clean(ctx context.Context) error {
+	s.receivedCtx = ctx
+	return nil
+}
+
+var _ CleanerCtx = (*ctxStoppableStruct)(nil)
+
+func TestInitializerCtx(t *testing.T) {
+	s := &ctxInitializableStruct{}
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "v")
+
+	New().
+		WithContext(ctx).
+		WithObject(s).
+		InitializeGraph()
+
+	assert.Equal(t, "v", s.receivedCtx.Value(ctxKey{}))
+}
+
+func TestCleanerCtx(t *testing.T) {
+	s := &ctxStoppableStruct{}
+
+	di := New().
+		WithObject(s).
+		InitializeGraph()
+
+	di.Stop(time.Minute, false)
+
+	assert.NotNil(t, s.receivedCtx)
+}
+
+func TestInitializerCtxPromotedFromEmbeddedStruct(t *testing.T) {
+	type both struct {
+		ctxInitializableStruct
+		plainCalled bool
+	}
+	b := &both{}
+	// A Go type can't have both Init() and Init(ctx) methods, so a preference between the two
+	// can't be observed here; this only asserts that an Init(ctx) promoted from an embedded
+	// struct is called.
+	New().
+		WithObject(b).
+		InitializeGraph()
+
+	assert.NotNil(t, b.receivedCtx)
+}
+
+func TestInitializeGraphRollsBackOnCancellation(t *testing.T) {
+	type A struct {
+		orderedInitializer
+		orderedCleaner
+	}
+	type B struct {
+		orderedInitializer
+		orderedCleaner
+		A *A `inject:""`
+	}
+
+	var order []string
+	var mu sync.Mutex
+	ctx, cancel := context.WithCancel(context.Background())
+
+	a := &A{
+		orderedInitializer: orderedInitializer{name: "A-init", order: &order},
+		orderedCleaner:     orderedCleaner{name: "A-clean", mu: &mu, order: &order},
+	}
+	b := &B{
+		orderedInitializer: orderedInitializer{name: "B-init", order: &order},
+		orderedCleaner:     orderedCleaner{name: "B-clean", mu: &mu, order: &order},
+	}
+
+	// Canceling as soon as A is initialized means B's Init must be skipped, and A must be
+	// rolled back via Clean:
+	a.orderedInitializer.onInit = cancel
+
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+		fmt.Println("Recovered in f", r)
+		assert.Equal(t, []string{"A-init", "A-clean"}, order)
+	}()
+
+	New().
+		WithContext(ctx).
+		WithObjects(b, a).
+		InitializeGraph()
+
+	t.Fail()
+}
+
+func TestInitializeGraphRollbackGivesCleanerCtxALiveContext(t *testing.T) {
+	type A struct {
+		orderedInitializer
+		ctxStoppableStruct
+	}
+
+	var order []string
+	ctx, cancel := context.WithCancel(context.Background())
+
+	a := &A{orderedInitializer: orderedInitializer{name: "A-init", order: &order}}
+	a.orderedInitializer.onInit = cancel
+
+	defer func() {
+		r := recover()
+		assert.NotNil(t, r)
+		fmt.Println("Recovered in f", r)
+		// If the rollback had reused the canceled context, a well-behaved CleanerCtx
+		// following the documented "watch ctx.Done() and abort" contract would see it
+		// already done - receivedCtx must be a separate, still-live context:
+		assert.NotNil(t, a.receivedCtx)
+		assert.Nil(t, a.receivedCtx.Err())
+	}()
+
+	New().
+		WithContext(ctx).
+		WithObject(a).
+		InitializeGraph()
+
+	t.Fail()
+}